@@ -2,7 +2,205 @@
 
 package webrtc
 
+import (
+	"errors"
+
+	"github.com/pion/webrtc/v4"
+)
+
 func (x *Channel) ErrorHandle(fn func(error)) {
 	x.errorHandle = fn
 	x.V.OnError(fn)
 }
+
+// ErrUnsupported is returned by operations that mirror the wasm build's media API, but that pion/webrtc does not currently expose a native equivalent for.
+var ErrUnsupported = errors.New("not supported")
+
+// Conn wraps a [webrtc.PeerConnection], mirroring the media related methods of the wasm build's Conn.
+type Conn struct {
+	V *webrtc.PeerConnection
+}
+
+// ConnNew wraps v to fit the portable media API.
+func ConnNew(v *webrtc.PeerConnection) *Conn {
+	return &Conn{V: v}
+}
+
+func (x *Conn) SenderRemove(sender Sender) error {
+	return x.V.RemoveTrack(sender.v)
+}
+
+func (x *Conn) TrackAdd(track webrtc.TrackLocal) (Sender, error) {
+	v, err := x.V.AddTrack(track)
+	return Sender{v}, err
+}
+
+// TrackHandle registers fn to be called whenever a new remote track is negotiated.
+//
+// Unlike the wasm variant, no stream list is passed, as pion has no equivalent of the browser's MediaStream; callers that need grouping should use the track's StreamID instead.
+func (x *Conn) TrackHandle(fn func(*webrtc.TrackRemote, Transceiver)) {
+	x.V.OnTrack(func(remote *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		var transceiver Transceiver
+		for _, t := range x.V.GetTransceivers() {
+			if t.Receiver() == receiver {
+				transceiver = Transceiver{t}
+				break
+			}
+		}
+		fn(remote, transceiver)
+	})
+}
+
+// RestartICE requests an ICE restart and publishes the resulting offer through renegotiate, as returned by SignalOffer or SignalPeer.
+//
+// pion/webrtc has no standalone PeerConnection.RestartICE method; the restart is instead requested through [webrtc.OfferOptions.ICERestart] when renegotiate builds its next offer.
+func (x *Conn) RestartICE(renegotiate Renegotiate) error {
+	return renegotiate(true)
+}
+
+// StateHandle registers fn to be called whenever the ICE connection state or the overall peer connection state changes.
+//
+// A common use is to watch for an ICEConnectionStateDisconnected state, and call RestartICE if it persists past an application defined timeout.
+func (x *Conn) StateHandle(fn func(webrtc.ICEConnectionState, webrtc.PeerConnectionState)) {
+	x.V.OnICEConnectionStateChange(func(s webrtc.ICEConnectionState) {
+		fn(s, x.V.ConnectionState())
+	})
+	x.V.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		fn(x.V.ICEConnectionState(), s)
+	})
+}
+
+// CodecParameters mirrors the wasm type of the same name, over a [webrtc.RTPCodecParameters].
+type CodecParameters struct {
+	v webrtc.RTPCodecParameters
+}
+
+func (x CodecParameters) Channels() uint {
+	return uint(x.v.Channels)
+}
+
+func (x CodecParameters) ClockRate() uint {
+	return uint(x.v.ClockRate)
+}
+
+func (x CodecParameters) MimeType() string {
+	return x.v.MimeType
+}
+
+func (x CodecParameters) PayloadType() byte {
+	return byte(x.v.PayloadType)
+}
+
+func (x CodecParameters) Sdp() string {
+	return x.v.SDPFmtpLine
+}
+
+const (
+	DirectionBoth    Direction = "sendrecv"
+	DirectionNone    Direction = "inactive"
+	DirectionReceive Direction = "recvonly"
+	DirectionSend    Direction = "sendonly"
+	DirectionStopped Direction = "stopped" // this must not be manually set on a Transceiver
+)
+
+type Direction string
+
+// EncodingParameters mirrors the wasm type of the same name, over the per-encoding parameters pion actually tracks.
+type EncodingParameters struct {
+	v webrtc.RTPEncodingParameters
+}
+
+func (x EncodingParameters) Rid() string {
+	return x.v.RID
+}
+
+func (x EncodingParameters) Ssrc() uint32 {
+	return uint32(x.v.SSRC)
+}
+
+func (x EncodingParameters) PayloadType() byte {
+	return byte(x.v.PayloadType)
+}
+
+// BitrateMax exists for symmetry with the wasm build, but pion/webrtc does not expose a max bitrate knob on an RTPSender's encodings; it always returns 0.
+func (x EncodingParameters) BitrateMax() uint {
+	return 0
+}
+
+// BitrateMaxSet exists for symmetry with the wasm build, but pion/webrtc does not expose a max bitrate knob on an RTPSender's encodings; it is a no-op here.
+func (x EncodingParameters) BitrateMaxSet(uint) {}
+
+// FramerateMax exists for symmetry with the wasm build, but pion/webrtc does not expose a max framerate knob on an RTPSender's encodings; it always returns 0.
+func (x EncodingParameters) FramerateMax() float64 {
+	return 0
+}
+
+// FramerateMaxSet exists for symmetry with the wasm build, but pion/webrtc does not expose a max framerate knob on an RTPSender's encodings; it is a no-op here.
+func (x EncodingParameters) FramerateMaxSet(float64) {}
+
+// Downscale exists for symmetry with the wasm build, but pion/webrtc does not expose a downscale knob on an RTPSender's encodings; it always returns 0.
+func (x EncodingParameters) Downscale() float64 {
+	return 0
+}
+
+// DownscaleSet exists for symmetry with the wasm build, but pion/webrtc does not expose a downscale knob on an RTPSender's encodings; it is a no-op here.
+func (x EncodingParameters) DownscaleSet(float64) {}
+
+// PtimeSet exists for symmetry with the wasm build, but pion/webrtc does not expose a ptime knob on an RTPSender's encodings; it is a no-op here.
+func (x EncodingParameters) PtimeSet(uint) {}
+
+// SendParameters mirrors the wasm type of the same name, over a [webrtc.RTPSendParameters].
+type SendParameters struct {
+	v webrtc.RTPSendParameters
+}
+
+func (x SendParameters) Codecs() []CodecParameters {
+	o := make([]CodecParameters, len(x.v.Codecs))
+	for i, c := range x.v.Codecs {
+		o[i] = CodecParameters{c}
+	}
+	return o
+}
+
+func (x SendParameters) Encodings() []EncodingParameters {
+	o := make([]EncodingParameters, len(x.v.Encodings))
+	for i, e := range x.v.Encodings {
+		o[i] = EncodingParameters{e}
+	}
+	return o
+}
+
+// Sender wraps a [webrtc.RTPSender], mirroring the wasm type of the same name.
+type Sender struct {
+	v *webrtc.RTPSender
+}
+
+func (x Sender) Parameters() SendParameters {
+	return SendParameters{x.v.GetParameters()}
+}
+
+// ParametersSet exists for symmetry with the wasm build, but pion/webrtc has no RTPSender.SetParameters; it always returns [ErrUnsupported].
+func (x Sender) ParametersSet(SendParameters) error {
+	return ErrUnsupported
+}
+
+func (x Sender) TrackReplace(track webrtc.TrackLocal) error {
+	return x.v.ReplaceTrack(track)
+}
+
+// Transceiver wraps a [webrtc.RTPTransceiver], mirroring the wasm type of the same name.
+type Transceiver struct {
+	v *webrtc.RTPTransceiver
+}
+
+// Direction returns the actual current direction, which might differ from the previously set one.
+func (x Transceiver) Direction() Direction {
+	return Direction(x.v.Direction().String())
+}
+
+// DirectionSet exists for symmetry with the wasm build, but pion/webrtc has no public setter for a transceiver's direction; it is a no-op here.
+func (x Transceiver) DirectionSet(Direction) {}
+
+func (x Transceiver) Stop() {
+	x.v.Stop() // errors not reported, to match the wasm build's signature
+}
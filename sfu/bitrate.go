@@ -0,0 +1,184 @@
+//go:build !(wasm && js)
+
+package sfu
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// upshiftStableFor is how long a [LayerSelector]'s bandwidth estimate must stay above the next layer's rate before upshifting to it.
+// Variable rather than const so tests can shrink it.
+var upshiftStableFor = 3 * time.Second
+
+// BitrateEstimator smooths receiver-side bandwidth reports from a single downstream peer into a target send bitrate, in bits per second.
+//
+// Only REMB is currently decoded (see [Track.readDownstreamRTCP]). TWCC feedback is received but discarded: computing a bitrate
+// from it needs per-packet send sizes keyed by transport-wide sequence number, which in turn needs this forwarder to tag
+// outgoing packets with a negotiated transport-cc header extension — neither of which exists yet. This matters because real
+// browsers frequently disable REMB in favor of TWCC, in which case Estimate never moves off its initial value. Tracked as
+// follow-up work, not implemented here.
+// The zero value is ready to use, and is what [bitrateEstimatorNew] returns.
+type BitrateEstimator struct {
+	mu    sync.Mutex
+	raw   uint64
+	value uint64
+	have  bool
+}
+
+func bitrateEstimatorNew() *BitrateEstimator {
+	return &BitrateEstimator{}
+}
+
+// Update feeds a newly reported bitrate sample.
+func (x *BitrateEstimator) Update(bps uint64) {
+	x.mu.Lock()
+	x.raw = bps
+	if !x.have {
+		x.have = true
+		x.value = bps
+	} else {
+		// EWMA; biased towards a quick reaction, as REMB reports already arrive at a low rate.
+		x.value = (x.value + bps) / 2
+	}
+	x.mu.Unlock()
+}
+
+// Estimate returns the current smoothed bitrate estimate, in bits per second. Zero means no report has been received yet.
+func (x *BitrateEstimator) Estimate() uint64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.value
+}
+
+// layerBitrate is an EWMA estimate of a single simulcast layer's send bitrate, fed by the size of every packet forwarded on it.
+type layerBitrate struct {
+	mu    sync.Mutex
+	value float64 // bytes per second
+	last  time.Time
+}
+
+// Update accounts for a newly forwarded packet of n bytes.
+func (x *layerBitrate) Update(n int) {
+	now := time.Now()
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.last.IsZero() {
+		x.last = now
+		return
+	}
+
+	dt := now.Sub(x.last).Seconds()
+	x.last = now
+	if dt <= 0 {
+		return
+	}
+
+	const alpha = 0.2
+	instant := float64(n) / dt
+	x.value += (instant - x.value) * alpha
+}
+
+// Get returns the current bitrate estimate, in bits per second.
+func (x *layerBitrate) Get() uint64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return uint64(x.value * 8)
+}
+
+// LayerSelector picks which simulcast layer a single downstream subscriber should receive, given a bandwidth estimate and each layer's own observed bitrate.
+// It downshifts as soon as the estimate no longer covers the current layer, but only upshifts once the estimate has comfortably covered the next layer up for [upshiftStableFor].
+// The zero value is ready to use, and is what [layerSelectorNew] returns.
+type LayerSelector struct {
+	mu         sync.Mutex
+	current    string
+	haveLayers bool
+	aboveSince time.Time
+}
+
+func layerSelectorNew() *LayerSelector {
+	return &LayerSelector{}
+}
+
+// Select returns the rid of the layer that should now be forwarded, given a bandwidth estimate and the most recently observed bitrate of every known layer.
+func (x *LayerSelector) Select(estimate uint64, rates map[string]uint64) string {
+	order := rankLayers(rates)
+	if len(order) == 0 {
+		return ""
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.haveLayers {
+		x.haveLayers = true
+		x.current = order[0]
+	}
+
+	// the current layer may have disappeared (e.g. the publisher stopped a simulcast encoding)
+	if _, ok := rates[x.current]; !ok {
+		x.current = order[0]
+	}
+
+	if estimate < rates[x.current] {
+		x.current = bestFit(order, rates, estimate)
+		x.aboveSince = time.Time{}
+		return x.current
+	}
+
+	next := nextAbove(order, x.current)
+	if next == "" {
+		return x.current
+	}
+
+	if estimate < rates[next] {
+		x.aboveSince = time.Time{}
+		return x.current
+	}
+
+	if x.aboveSince.IsZero() {
+		x.aboveSince = time.Now()
+		return x.current
+	}
+	if time.Since(x.aboveSince) >= upshiftStableFor {
+		x.current = next
+		x.aboveSince = time.Time{}
+	}
+	return x.current
+}
+
+// rankLayers returns the rids in rates, ascending by bitrate.
+func rankLayers(rates map[string]uint64) []string {
+	order := make([]string, 0, len(rates))
+	for rid := range rates {
+		order = append(order, rid)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return rates[order[i]] < rates[order[j]]
+	})
+	return order
+}
+
+// bestFit returns the highest ranked layer whose rate does not exceed estimate, falling back to the lowest layer if none fit.
+func bestFit(order []string, rates map[string]uint64, estimate uint64) string {
+	best := order[0]
+	for _, rid := range order {
+		if rates[rid] <= estimate {
+			best = rid
+		}
+	}
+	return best
+}
+
+// nextAbove returns the layer ranked immediately above current, or "" if current is already the highest.
+func nextAbove(order []string, current string) string {
+	for i, rid := range order {
+		if rid == current && i+1 < len(order) {
+			return order[i+1]
+		}
+	}
+	return ""
+}
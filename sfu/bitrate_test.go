@@ -0,0 +1,86 @@
+//go:build !(wasm && js)
+
+package sfu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBitrateEstimatorUpdate(t *testing.T) {
+	x := bitrateEstimatorNew()
+
+	if got := x.Estimate(); got != 0 {
+		t.Fatalf("Estimate() before any Update = %d, want 0", got)
+	}
+
+	x.Update(1000)
+	if got := x.Estimate(); got != 1000 {
+		t.Fatalf("Estimate() after first Update = %d, want 1000", got)
+	}
+
+	x.Update(2000) // EWMA towards the new sample, not a jump straight to it
+	if got := x.Estimate(); got <= 1000 || got >= 2000 {
+		t.Fatalf("Estimate() = %d, want strictly between 1000 and 2000", got)
+	}
+}
+
+func TestLayerSelectorStartsOnLowestLayer(t *testing.T) {
+	x := layerSelectorNew()
+
+	// even with plenty of headroom, a fresh selector starts low and only
+	// upshifts once the estimate has stayed above the next layer for a while.
+	rates := map[string]uint64{"low": 100, "mid": 500, "high": 1000}
+	if got := x.Select(1000, rates); got != "low" {
+		t.Fatalf("Select() = %q, want %q", got, "low")
+	}
+}
+
+func TestLayerSelectorDownshiftsImmediately(t *testing.T) {
+	x := layerSelectorNew()
+
+	rates := map[string]uint64{"low": 100, "mid": 500, "high": 1000}
+	x.current = "high" // as if a previous, now-stale selection had upshifted
+	x.haveLayers = true
+
+	if got := x.Select(400, rates); got != "low" {
+		t.Fatalf("Select() = %q, want %q (estimate no longer covers mid or high)", got, "low")
+	}
+}
+
+func TestLayerSelectorUpshiftWaitsForStability(t *testing.T) {
+	orig := upshiftStableFor
+	upshiftStableFor = 20 * time.Millisecond
+	defer func() { upshiftStableFor = orig }()
+
+	x := layerSelectorNew()
+	rates := map[string]uint64{"low": 100, "high": 1000}
+
+	if got := x.Select(100, rates); got != "low" {
+		t.Fatalf("Select() = %q, want %q", got, "low")
+	}
+
+	// estimate now comfortably covers "high", but not for long enough yet
+	if got := x.Select(1000, rates); got != "low" {
+		t.Fatalf("Select() = %q, want %q (should not upshift before upshiftStableFor elapses)", got, "low")
+	}
+
+	time.Sleep(upshiftStableFor * 2)
+
+	if got := x.Select(1000, rates); got != "high" {
+		t.Fatalf("Select() = %q, want %q (should upshift once stable long enough)", got, "high")
+	}
+}
+
+func TestLayerSelectorCurrentLayerDisappearing(t *testing.T) {
+	x := layerSelectorNew()
+
+	x.current = "high" // as if a previous selection had upshifted
+	x.haveLayers = true
+
+	// "high" no longer exists; Select must fall back rather than get stuck on a removed rid
+	got := x.Select(1000, map[string]uint64{"low": 100})
+	if got != "low" {
+		t.Fatalf("Select() = %q, want %q", got, "low")
+	}
+}
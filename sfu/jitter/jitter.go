@@ -0,0 +1,62 @@
+// Package jitter estimates the interarrival jitter of an RTP stream, following the algorithm described in RFC 3550, appendix A.8.
+package jitter
+
+import (
+	"sync"
+	"time"
+)
+
+// Estimator computes a running interarrival jitter estimate for a single RTP stream.
+// The zero value is not usable; use [New].
+type Estimator struct {
+	clockRate uint32
+
+	mu            sync.Mutex
+	have          bool
+	lastArrival   int64
+	lastTimestamp uint32
+	value         float64
+}
+
+// New returns an Estimator for a stream with the given RTP clock rate.
+func New(clockRate uint32) *Estimator {
+	return &Estimator{clockRate: clockRate}
+}
+
+// Update feeds the RTP timestamp of a packet that has just arrived, and returns the updated jitter estimate, converted from clock ticks to a duration.
+func (x *Estimator) Update(timestamp uint32) time.Duration {
+	arrival := int64(time.Now().UnixNano()) * int64(x.clockRate) / int64(time.Second)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.have {
+		x.have = true
+		x.lastArrival = arrival
+		x.lastTimestamp = timestamp
+		return 0
+	}
+
+	d := float64((arrival - x.lastArrival) - (int64(timestamp) - int64(x.lastTimestamp)))
+	if d < 0 {
+		d = -d
+	}
+	x.value += (d - x.value) / 16
+
+	x.lastArrival = arrival
+	x.lastTimestamp = timestamp
+
+	return x.duration()
+}
+
+// Get returns the most recently computed jitter estimate, as a duration.
+func (x *Estimator) Get() time.Duration {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.duration()
+}
+
+// duration converts the running estimate, in clock ticks, to a time.Duration. Callers must hold mu.
+func (x *Estimator) duration() time.Duration {
+	return time.Duration(x.value / float64(x.clockRate) * float64(time.Second))
+}
@@ -0,0 +1,33 @@
+package jitter
+
+import "testing"
+
+func TestUpdateFirstSampleIsZero(t *testing.T) {
+	x := New(90000)
+	if d := x.Update(12345); d != 0 {
+		t.Fatalf("got %v, want 0", d)
+	}
+}
+
+// A packet arriving slightly out of order (a lower RTP timestamp than the
+// previous one) is normal on a real network and must not be mistaken for a
+// huge jump in jitter.
+func TestUpdateWrapSafeOnOutOfOrderTimestamp(t *testing.T) {
+	x := New(90000)
+	x.Update(1000)
+	d := x.Update(900) // timestamp went backwards by 100 ticks
+
+	const max = 1e9 // generous bound; a correct estimate is on the order of microseconds
+	if d < 0 || d > max {
+		t.Fatalf("got %v, want a small non-negative duration (< %v)", d, max)
+	}
+}
+
+func TestGetReflectsLastUpdate(t *testing.T) {
+	x := New(90000)
+	x.Update(1000)
+	want := x.Update(1100)
+	if got := x.Get(); got != want {
+		t.Fatalf("Get() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+// Package packetcache implements a fixed-size ring buffer of recently sent RTP packets, keyed by sequence number.
+//
+// It lets a forwarder answer NACK requests by retransmitting a packet it has already sent, without having to go back to the upstream source.
+package packetcache
+
+import "sync"
+
+// size must be a power of two, so that seq%size can be computed with a mask.
+const size = 512
+
+type entry struct {
+	valid  bool
+	seq    uint16
+	packet []byte
+}
+
+// Cache is a ring buffer of the most recently stored packets.
+// The zero value is an empty Cache ready to use, and is what [New] returns.
+type Cache struct {
+	mu      sync.Mutex
+	entries [size]entry
+}
+
+// New returns an empty Cache.
+func New() *Cache {
+	return &Cache{}
+}
+
+// Store saves a copy of packet, keyed by seq. It overwrites whatever packet previously occupied that slot.
+func (x *Cache) Store(seq uint16, packet []byte) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e := &x.entries[seq&(size-1)]
+	e.valid = true
+	e.seq = seq
+	e.packet = append(e.packet[:0], packet...)
+}
+
+// Get returns a copy of the cached packet with the given sequence number.
+// ok is false if the packet is not in the cache, either because it was never stored or because it has since been overwritten.
+func (x *Cache) Get(seq uint16) (packet []byte, ok bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e := &x.entries[seq&(size-1)]
+	if !e.valid || e.seq != seq {
+		return nil, false
+	}
+
+	packet = make([]byte, len(e.packet))
+	copy(packet, e.packet)
+	return packet, true
+}
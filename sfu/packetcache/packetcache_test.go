@@ -0,0 +1,57 @@
+package packetcache
+
+import "testing"
+
+func TestStoreGet(t *testing.T) {
+	c := New()
+
+	c.Store(5, []byte("hello"))
+
+	got, ok := c.Get(5)
+	if !ok {
+		t.Fatal("Get(5) = _, false, want true")
+	}
+	if string(got) != "hello" {
+		t.Fatalf("Get(5) = %q, want %q", got, "hello")
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	c := New()
+
+	if _, ok := c.Get(1); ok {
+		t.Fatal("Get on empty cache returned ok = true")
+	}
+}
+
+func TestStoreOverwritesRingSlot(t *testing.T) {
+	c := New()
+
+	c.Store(0, []byte("old"))
+	c.Store(size, []byte("new")) // same slot as seq 0, since size is a power of two
+
+	if _, ok := c.Get(0); ok {
+		t.Fatal("Get(0) still ok after its slot was overwritten by seq = size")
+	}
+
+	got, ok := c.Get(size)
+	if !ok {
+		t.Fatal("Get(size) = _, false, want true")
+	}
+	if string(got) != "new" {
+		t.Fatalf("Get(size) = %q, want %q", got, "new")
+	}
+}
+
+func TestStoreCopiesPacket(t *testing.T) {
+	c := New()
+
+	packet := []byte("mutate me")
+	c.Store(1, packet)
+	packet[0] = 'X'
+
+	got, _ := c.Get(1)
+	if string(got) != "mutate me" {
+		t.Fatalf("Get(1) = %q, want stored copy unaffected by later mutation", got)
+	}
+}
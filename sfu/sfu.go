@@ -0,0 +1,472 @@
+//go:build !(wasm && js)
+
+// Package sfu turns a set of [webrtc.PeerConnection]s into a selective forwarding unit: RTP received from any upstream connection is forwarded to every downstream connection, picking a simulcast layer per downstream according to its own available bandwidth.
+//
+// This lets an application build many-to-many rooms on top of [github.com/pion/webrtc/v4] without juggling per-track plumbing itself.
+package sfu
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blitz-frost/webrtc/sfu/jitter"
+	"github.com/blitz-frost/webrtc/sfu/packetcache"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// selectInterval is how often each subscriber re-evaluates its bandwidth estimate and simulcast layer choice.
+const selectInterval = 200 * time.Millisecond
+
+// Router forwards RTP between a set of upstream and downstream peer connections.
+// The zero value is ready to use.
+type Router struct {
+	mu          sync.Mutex
+	downstreams []*webrtc.PeerConnection
+	peerIds     map[*webrtc.PeerConnection]uint64
+	nextPeerId  uint64
+	tracks      []*Track
+	byKey       map[string]*Track // keyed by StreamID+"/"+ID, to group simulcast layers of the same track
+	trackFn     func(*Track)
+	bitrateFn   func(peer, bps uint64)
+}
+
+// RouterNew returns an empty Router.
+func RouterNew() *Router {
+	return &Router{}
+}
+
+// AddUpstream makes conn a source of forwarded tracks.
+// Every track conn negotiates from this point on is forwarded to all current and future downstream connections.
+// Simulcast encodings of the same track (distinguished by RID) are grouped into a single [Track], one of whose layers is selected per downstream subscriber.
+func (x *Router) AddUpstream(conn *webrtc.PeerConnection) error {
+	conn.OnTrack(func(remote *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		key := remote.StreamID() + "/" + remote.ID()
+
+		x.mu.Lock()
+		t, ok := x.byKey[key]
+		if !ok {
+			t = trackNew(x, conn)
+			if x.byKey == nil {
+				x.byKey = make(map[string]*Track)
+			}
+			x.byKey[key] = t
+			x.tracks = append(x.tracks, t)
+		}
+		downstreams := append([]*webrtc.PeerConnection(nil), x.downstreams...)
+		fn := x.trackFn
+		x.mu.Unlock()
+
+		l := t.addLayer(remote)
+
+		if !ok {
+			for _, d := range downstreams {
+				t.subscribe(d, x.peerId(d))
+			}
+			if fn != nil {
+				fn(t)
+			}
+		}
+
+		go t.readLayerRTP(l)
+		go t.readUpstreamRTCP(receiver)
+	})
+	return nil
+}
+
+// AddDownstream makes conn a destination for every track forwarded by the Router, present and future.
+func (x *Router) AddDownstream(conn *webrtc.PeerConnection) error {
+	x.mu.Lock()
+	x.nextPeerId++
+	id := x.nextPeerId
+	if x.peerIds == nil {
+		x.peerIds = make(map[*webrtc.PeerConnection]uint64)
+	}
+	x.peerIds[conn] = id
+	x.downstreams = append(x.downstreams, conn)
+	tracks := append([]*Track(nil), x.tracks...)
+	x.mu.Unlock()
+
+	for _, t := range tracks {
+		if err := t.subscribe(conn, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrackHandle registers a callback invoked once for every upstream track accepted by the Router, giving the application a handle to, for example, call [Track.RequestKeyframe] on.
+func (x *Router) TrackHandle(fn func(*Track)) {
+	x.mu.Lock()
+	x.trackFn = fn
+	x.mu.Unlock()
+}
+
+// OnBitrate registers a callback invoked every [selectInterval] for each forwarded track a downstream peer is subscribed to, with the latest bandwidth estimate of that subscription; the peer is identified by the id assigned to it by [Router.AddDownstream] (counted from 1, in call order).
+// A peer subscribed to several tracks is therefore reported once per track each interval, and a peer with no subscribed tracks is not reported.
+// Applications can use this to drive their own encoder ladder, in addition to the Router's own simulcast layer selection.
+func (x *Router) OnBitrate(fn func(peer, bps uint64)) {
+	x.mu.Lock()
+	x.bitrateFn = fn
+	x.mu.Unlock()
+}
+
+func (x *Router) peerId(conn *webrtc.PeerConnection) uint64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.peerIds[conn]
+}
+
+// layer is a single simulcast encoding of an upstream Track.
+// A non-simulcast track has exactly one layer, with an empty rid.
+type layer struct {
+	rid     string
+	remote  *webrtc.TrackRemote
+	jitter  *jitter.Estimator
+	bitrate *layerBitrate
+}
+
+func (x *layer) sendPLI(upConn *webrtc.PeerConnection) error {
+	return upConn.WriteRTCP([]rtcp.Packet{
+		&rtcp.PictureLossIndication{MediaSSRC: uint32(x.remote.SSRC())},
+	})
+}
+
+func (x *layer) sendFIR(upConn *webrtc.PeerConnection, seqno uint8) error {
+	return upConn.WriteRTCP([]rtcp.Packet{
+		&rtcp.FullIntraRequest{
+			MediaSSRC: uint32(x.remote.SSRC()),
+			FIR:       []rtcp.FIREntry{{SSRC: uint32(x.remote.SSRC()), SequenceNumber: seqno}},
+		},
+	})
+}
+
+// Track represents a single forwarded track, possibly simulcast, coming from one upstream connection.
+type Track struct {
+	router *Router
+	upConn *webrtc.PeerConnection
+
+	mu     sync.Mutex
+	layers map[string]*layer
+	subs   []*subscriber
+	fir    uint8 // incremented on every FullIntraRequest sent
+}
+
+func trackNew(router *Router, upConn *webrtc.PeerConnection) *Track {
+	return &Track{
+		router: router,
+		upConn: upConn,
+		layers: make(map[string]*layer),
+	}
+}
+
+func (x *Track) addLayer(remote *webrtc.TrackRemote) *layer {
+	l := &layer{
+		rid:     remote.RID(),
+		remote:  remote,
+		jitter:  jitter.New(remote.Codec().ClockRate),
+		bitrate: &layerBitrate{},
+	}
+
+	x.mu.Lock()
+	x.layers[l.rid] = l
+	x.mu.Unlock()
+
+	return l
+}
+
+func (x *Track) layer(rid string) *layer {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.layers[rid]
+}
+
+// rates returns the most recently observed bitrate of every known layer, in bits per second.
+func (x *Track) rates() map[string]uint64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	o := make(map[string]uint64, len(x.layers))
+	for rid, l := range x.layers {
+		o[rid] = l.bitrate.Get()
+	}
+	return o
+}
+
+// Jitter returns the most recently estimated interarrival jitter of the named simulcast layer (empty rid for a non-simulcast track), and whether that layer is currently known.
+func (x *Track) Jitter(rid string) (time.Duration, bool) {
+	x.mu.Lock()
+	l := x.layers[rid]
+	x.mu.Unlock()
+
+	if l == nil {
+		return 0, false
+	}
+	return l.jitter.Get(), true
+}
+
+func (x *Track) nextFIR() uint8 {
+	x.mu.Lock()
+	x.fir++
+	n := x.fir
+	x.mu.Unlock()
+	return n
+}
+
+// subscribe adds conn as a destination of the track, best-effort: a failure to negotiate the new track with conn is not fatal to the rest of the room.
+func (x *Track) subscribe(conn *webrtc.PeerConnection, peerId uint64) error {
+	x.mu.Lock()
+	var sample *layer
+	for _, l := range x.layers {
+		sample = l
+		break // arbitrary starting layer; corrected by the subscriber's first selection tick
+	}
+	x.mu.Unlock()
+
+	if sample == nil {
+		return nil // no layer negotiated yet
+	}
+
+	local, err := webrtc.NewTrackLocalStaticRTP(sample.remote.Codec().RTPCodecCapability, sample.remote.ID(), sample.remote.StreamID())
+	if err != nil {
+		return err
+	}
+
+	sender, err := conn.AddTrack(local)
+	if err != nil {
+		return err
+	}
+
+	sub := &subscriber{
+		peerId:      peerId,
+		local:       local,
+		sender:      sender,
+		cache:       packetcache.New(),
+		estimator:   bitrateEstimatorNew(),
+		selector:    layerSelectorNew(),
+		selectedRID: sample.rid,
+		done:        make(chan struct{}),
+	}
+
+	x.mu.Lock()
+	x.subs = append(x.subs, sub)
+	x.mu.Unlock()
+
+	go x.readDownstreamRTCP(sub)
+	go sub.run(x)
+
+	return nil
+}
+
+// readLayerRTP pulls packets off one upstream layer and forwards them to every subscriber currently selecting that layer.
+func (x *Track) readLayerRTP(l *layer) {
+	for {
+		p, _, err := l.remote.ReadRTP()
+		if err != nil {
+			return
+		}
+
+		l.jitter.Update(p.Timestamp)
+		l.bitrate.Update(p.MarshalSize())
+
+		x.mu.Lock()
+		subs := append([]*subscriber(nil), x.subs...)
+		x.mu.Unlock()
+
+		for _, sub := range subs {
+			sub.forward(l.rid, l.remote.Codec().ClockRate, p)
+		}
+	}
+}
+
+// readUpstreamRTCP drains RTCP coming from the upstream source.
+// pion requires an RTPReceiver's RTCP to be read out, or it builds up indefinitely; the Router itself currently has no use for it.
+func (x *Track) readUpstreamRTCP(receiver *webrtc.RTPReceiver) {
+	for {
+		if _, _, err := receiver.ReadRTCP(); err != nil {
+			return
+		}
+	}
+}
+
+// readDownstreamRTCP answers feedback from a single subscriber: NACKs are served from that subscriber's own packet cache, REMB reports feed its bandwidth estimate, and keyframe requests are forwarded to whichever upstream layer it is currently watching.
+// TWCC reports are received but not yet decoded into a bandwidth estimate; see the [BitrateEstimator] doc comment.
+func (x *Track) readDownstreamRTCP(sub *subscriber) {
+	defer close(sub.done)
+
+	for {
+		pkts, _, err := sub.sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+
+		for _, pkt := range pkts {
+			switch p := pkt.(type) {
+			case *rtcp.TransportLayerNack:
+				sub.resend(p)
+			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				sub.estimator.Update(uint64(p.Bitrate))
+			case *rtcp.TransportLayerCC:
+				// Not decoded: doing so usefully would require tagging every forwarded
+				// packet with a transport-wide sequence number extension on the way out,
+				// which this forwarder does not currently write. Tracked as follow-up;
+				// see BitrateEstimator's doc comment.
+			case *rtcp.PictureLossIndication:
+				if l := x.layer(sub.currentRID()); l != nil {
+					l.sendPLI(x.upConn)
+				}
+			case *rtcp.FullIntraRequest:
+				if l := x.layer(sub.currentRID()); l != nil {
+					l.sendFIR(x.upConn, x.nextFIR())
+				}
+			}
+		}
+	}
+}
+
+// RequestKeyframe asks the upstream source to produce a new keyframe on every known layer.
+func (x *Track) RequestKeyframe() error {
+	x.mu.Lock()
+	layers := make([]*layer, 0, len(x.layers))
+	for _, l := range x.layers {
+		layers = append(layers, l)
+	}
+	x.mu.Unlock()
+
+	var firstErr error
+	for _, l := range layers {
+		if err := l.sendFIR(x.upConn, x.nextFIR()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// rewriter splices a subscriber's view of a Track back into a single contiguous sequence/timestamp space across simulcast layer switches.
+type rewriter struct {
+	init bool
+	rid  string
+
+	seqOffset uint16
+	lastSeq   uint16
+
+	tsOffset uint32
+	lastTs   uint32
+}
+
+// apply rewrites p to continue the subscriber's output stream. tsStep approximates the layer's frame spacing, used only to bridge the gap left by a layer switch; exact continuity is not required, only monotonicity.
+func (x *rewriter) apply(p rtp.Packet, rid string, tsStep uint32) rtp.Packet {
+	if x.init && x.rid != rid {
+		x.seqOffset = x.lastSeq + 1 - p.SequenceNumber
+		x.tsOffset = x.lastTs + tsStep - p.Timestamp
+	}
+	x.init = true
+	x.rid = rid
+
+	p.SequenceNumber += x.seqOffset
+	p.Timestamp += x.tsOffset
+
+	x.lastSeq = p.SequenceNumber
+	x.lastTs = p.Timestamp
+
+	return p
+}
+
+// subscriber is a single downstream leg of a forwarded Track.
+type subscriber struct {
+	peerId    uint64
+	local     *webrtc.TrackLocalStaticRTP
+	sender    *webrtc.RTPSender
+	cache     *packetcache.Cache
+	estimator *BitrateEstimator
+	selector  *LayerSelector
+
+	mu          sync.Mutex
+	selectedRID string
+	rewrite     rewriter
+
+	done chan struct{} // closed once readDownstreamRTCP returns, to stop run
+}
+
+func (x *subscriber) currentRID() string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.selectedRID
+}
+
+// forward writes p to the subscriber's local track, provided rid is the layer it is currently watching.
+func (x *subscriber) forward(rid string, clockRate uint32, p *rtp.Packet) {
+	x.mu.Lock()
+	if x.selectedRID != rid {
+		x.mu.Unlock()
+		return
+	}
+	out := x.rewrite.apply(*p, rid, clockRate/30)
+	x.mu.Unlock()
+
+	if b, err := out.Marshal(); err == nil {
+		x.cache.Store(out.SequenceNumber, b)
+	}
+	x.local.WriteRTP(&out)
+}
+
+// resend replays cached packets in response to a NACK. Since the cache holds exactly what was sent to this subscriber, no further rewriting is needed.
+func (x *subscriber) resend(nack *rtcp.TransportLayerNack) {
+	for _, pair := range nack.Nacks {
+		for _, seq := range pair.PacketList() {
+			b, ok := x.cache.Get(seq)
+			if !ok {
+				continue
+			}
+
+			p := rtp.Packet{}
+			if err := p.Unmarshal(b); err != nil {
+				continue
+			}
+
+			x.local.WriteRTP(&p)
+		}
+	}
+}
+
+// run periodically re-evaluates the subscriber's bandwidth estimate and simulcast layer choice, until sub.done is closed.
+func (x *subscriber) run(t *Track) {
+	ticker := time.NewTicker(selectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-x.done:
+			return
+		case <-ticker.C:
+		}
+
+		estimate := x.estimator.Estimate()
+
+		t.router.mu.Lock()
+		bitrateFn := t.router.bitrateFn
+		t.router.mu.Unlock()
+		if bitrateFn != nil {
+			bitrateFn(x.peerId, estimate)
+		}
+
+		rates := t.rates()
+		if len(rates) == 0 {
+			continue
+		}
+
+		rid := x.selector.Select(estimate, rates)
+
+		x.mu.Lock()
+		changed := x.selectedRID != rid
+		x.selectedRID = rid
+		x.mu.Unlock()
+
+		if changed {
+			if l := t.layer(rid); l != nil {
+				l.sendPLI(t.upConn)
+			}
+		}
+	}
+}
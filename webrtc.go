@@ -1,6 +1,7 @@
 package webrtc
 
 import (
+	"math"
 	"sync"
 
 	"github.com/blitz-frost/io"
@@ -17,23 +18,56 @@ var (
 type Channel struct {
 	V *webrtc.DataChannel
 
-	buf []byte // buffer outgoing messages
-	dst msg.ReaderTaker
+	buf         []byte // buffer outgoing messages
+	dst         msg.ReaderTaker
+	errorHandle func(error)
+
+	bufMux    sync.Mutex
+	bufCond   *sync.Cond
+	threshold uint64 // Writer high-watermark; see BufferedAmountLowThreshold
 }
 
 // ChannelNew wraps a [webrtc.DataChannel] to fit the msg framework.
 func ChannelNew(v *webrtc.DataChannel) *Channel {
 	x := Channel{
-		V:   v,
-		buf: []byte{},
-		dst: msg.Void{},
+		V:         v,
+		buf:       []byte{},
+		dst:       msg.Void{},
+		threshold: math.MaxUint64, // unbounded until BufferedAmountLowThreshold is called
 	}
+	x.bufCond = sync.NewCond(&x.bufMux)
 	v.OnMessage(func(m webrtc.DataChannelMessage) {
 		x.dst.ReaderTake((*io.BytesReader)(&m.Data))
 	})
+	v.OnBufferedAmountLow(func() {
+		x.bufMux.Lock()
+		x.bufCond.Broadcast()
+		x.bufMux.Unlock()
+	})
 	return &x
 }
 
+// BufferedAmount returns the number of bytes currently queued on the underlying data channel, awaiting transmission to the peer.
+func (x *Channel) BufferedAmount() uint64 {
+	return x.V.BufferedAmount()
+}
+
+// BufferedAmountLowThreshold sets the high-watermark past which a blocking Writer (obtained through [Channel.Writer]) will make its Close call wait until the buffered amount has drained back down to it.
+// A [Channel.WriterAsync] Writer's Close does not block on it, but its background send waits on the same threshold before calling Send.
+func (x *Channel) BufferedAmountLowThreshold(n uint64) {
+	x.V.SetBufferedAmountLowThreshold(n)
+	x.threshold = n
+}
+
+// waitBufferedLow blocks while the underlying buffered amount exceeds the configured threshold.
+func (x *Channel) waitBufferedLow() {
+	x.bufMux.Lock()
+	for x.V.BufferedAmount() > x.threshold {
+		x.bufCond.Wait()
+	}
+	x.bufMux.Unlock()
+}
+
 func (x *Channel) Close() error {
 	return x.V.Close()
 }
@@ -42,13 +76,6 @@ func (x *Channel) CloseHandle(fn func()) {
 	x.V.OnClose(fn)
 }
 
-/*
-Not present in wasm version
-func (x Channel) ErrorHandle(fn func(error)) {
-	x.ch.OnError(fn)
-}
-*/
-
 func (x *Channel) OpenHandle(fn func()) {
 	x.V.OnOpen(fn)
 }
@@ -60,23 +87,67 @@ func (x *Channel) ReaderChain(dst msg.ReaderTaker) error {
 
 // The returned value is also a [msg.Canceler].
 // Not concurrent safe.
+//
+// Close blocks while the buffered amount exceeds the threshold set through [Channel.BufferedAmountLowThreshold], guarding against unbounded growth of the underlying send buffer over slow links.
 func (x *Channel) Writer() (msg.Writer, error) {
 	return (*writer)(x), nil
 }
 
+// WriterAsync is a non-blocking counterpart to Writer: its Close method returns immediately, and the eventual [webrtc.DataChannel.Send] result (which may have had to wait out the same backpressure as a Writer) is delivered on the returned channel instead.
+//
+// The returned value is also a [msg.Canceler].
+// Not concurrent safe.
+func (x *Channel) WriterAsync() (msg.Writer, <-chan error, error) {
+	ch := make(chan error, 1)
+	return &writerAsync{
+		Channel: x,
+		done:    ch,
+	}, ch, nil
+}
+
 // Sdp separates the webrtc.SessionDescription exported part, making it encoding agnostic.
 type Sdp struct {
 	Type   webrtc.SDPType
 	String string
 }
 
+// Candidate separates the webrtc.ICECandidateInit exported part, making it encoding agnostic.
+//
+// A zero value SDPMid/SDPMLineIndex is wire-compatible with peers that only populate String, as it reproduces the previous hardcoded single m-section behavior.
+//
+// End signals that ICE candidate gathering has completed, mirroring the nil candidate used by [webrtc.PeerConnection.OnICECandidate]. The remaining fields are meaningless when it is set.
+type Candidate struct {
+	String           string
+	SDPMid           string
+	SDPMLineIndex    uint16
+	UsernameFragment string
+	End              bool
+}
+
 type signaler struct {
-	fnCandidate func(string) error
+	fnCandidate func(Candidate) error
 	fnSdp       func(Sdp) error
 }
 
 func (x *signaler) candidate(candidate *webrtc.ICECandidate) error {
-	return x.fnCandidate(candidate.ToJSON().Candidate)
+	if candidate == nil {
+		return x.fnCandidate(Candidate{End: true})
+	}
+
+	json := candidate.ToJSON()
+	c := Candidate{
+		String: json.Candidate,
+	}
+	if json.SDPMid != nil {
+		c.SDPMid = *json.SDPMid
+	}
+	if json.SDPMLineIndex != nil {
+		c.SDPMLineIndex = *json.SDPMLineIndex
+	}
+	if json.UsernameFragment != nil {
+		c.UsernameFragment = *json.UsernameFragment
+	}
+	return x.fnCandidate(c)
 }
 
 func (x *signaler) sdp(sd webrtc.SessionDescription) error {
@@ -91,13 +162,21 @@ func (x *signaler) setup(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Li
 	mux := sync.Mutex{}
 
 	// answer side
-	lib.Register(CandidateProcedureName, func(s string) error {
-		zero := uint16(0)
-		empty := ""
+	lib.Register(CandidateProcedureName, func(c Candidate) error {
+		if c.End {
+			return conn.AddICECandidate(webrtc.ICECandidateInit{})
+		}
+
+		mid := c.SDPMid
+		mLineIndex := c.SDPMLineIndex
 		ci := webrtc.ICECandidateInit{
-			Candidate:     s,
-			SDPMid:        &empty,
-			SDPMLineIndex: &zero,
+			Candidate:     c.String,
+			SDPMid:        &mid,
+			SDPMLineIndex: &mLineIndex,
+		}
+		if c.UsernameFragment != "" {
+			uFrag := c.UsernameFragment
+			ci.UsernameFragment = &uFrag
 		}
 		return conn.AddICECandidate(ci)
 	})
@@ -128,10 +207,6 @@ func (x *signaler) setup(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Li
 	cli.Bind(SdpProcedureName, &x.fnSdp)
 
 	conn.OnICECandidate(func(candidate *webrtc.ICECandidate) {
-		if candidate == nil {
-			return
-		}
-
 		mux.Lock()
 		defer mux.Unlock()
 
@@ -166,13 +241,18 @@ func SignalAnswer(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Library)
 	return sig.setup(conn, cli, lib, answerFunc)
 }
 
+// Renegotiate starts a new offer/answer exchange over an already established signaling channel, as returned by SignalOffer and SignalPeer.
+//
+// Passing restart requests an ICE restart as part of the new offer; see [Conn.RestartICE].
+type Renegotiate func(restart bool) error
+
 // SignalOffer sets up the WebRTC offer side of the signaling process for a peer connection.
 //
 // The underlying RPC system must be capable of concurrent, as well as recursive calls.
 // Two procedures will be added, whose names are determined by the global variables CandidateProcedureName and SdpProcedureName.
 //
 // The returned function can be used to start the initial process, as well as renegotiation.
-func SignalOffer(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Library) (func() error, error) {
+func SignalOffer(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Library) (Renegotiate, error) {
 	sig := signaler{}
 	answerFunc := func() error { return nil }
 
@@ -180,8 +260,8 @@ func SignalOffer(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Library) (
 		return nil, err
 	}
 
-	fn := func() error {
-		offer, err := conn.CreateOffer(nil)
+	fn := func(restart bool) error {
+		offer, err := conn.CreateOffer(&webrtc.OfferOptions{ICERestart: restart})
 		if err != nil {
 			return err
 		}
@@ -195,6 +275,144 @@ func SignalOffer(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Library) (
 	return fn, nil
 }
 
+// SignalPeer sets up the WebRTC signaling process for a peer connection using the "perfect negotiation" pattern, letting either side trigger renegotiation without the two colliding.
+//
+// polite must be agreed upon out of band, with exactly one of the two peers being polite.
+// On a collision, the impolite side ignores the incoming offer (and any candidates that arrive before its own answer is processed), while the polite side rolls back its local offer and accepts the remote one instead.
+//
+// The underlying RPC system must be capable of concurrent, as well as recursive calls.
+// Two procedures will be added, whose names are determined by the global variables CandidateProcedureName and SdpProcedureName.
+//
+// The returned function can be used to start the initial negotiation, as well as any later renegotiation.
+func SignalPeer(conn *webrtc.PeerConnection, cli rpc.Client, lib rpc.Library, polite bool) (Renegotiate, error) {
+	sig := signaler{}
+	pending := make([]*webrtc.ICECandidate, 0)
+	mux := sync.Mutex{}
+
+	var (
+		makingOffer bool
+		ignoreOffer bool
+	)
+
+	flush := func() {
+		for _, candidate := range pending {
+			go sig.candidate(candidate)
+		}
+		pending = pending[:0]
+	}
+
+	// answer side
+	lib.Register(CandidateProcedureName, func(c Candidate) error {
+		mux.Lock()
+		ignore := ignoreOffer
+		mux.Unlock()
+
+		if c.End {
+			if ignore {
+				return nil
+			}
+			return conn.AddICECandidate(webrtc.ICECandidateInit{})
+		}
+
+		mid := c.SDPMid
+		mLineIndex := c.SDPMLineIndex
+		ci := webrtc.ICECandidateInit{
+			Candidate:     c.String,
+			SDPMid:        &mid,
+			SDPMLineIndex: &mLineIndex,
+		}
+		if c.UsernameFragment != "" {
+			uFrag := c.UsernameFragment
+			ci.UsernameFragment = &uFrag
+		}
+
+		if err := conn.AddICECandidate(ci); err != nil && !ignore {
+			return err
+		}
+		return nil
+	})
+	lib.Register(SdpProcedureName, func(s Sdp) error {
+		sdp := webrtc.SessionDescription{
+			Type: s.Type,
+			SDP:  s.String,
+		}
+
+		mux.Lock()
+		collision := s.Type == webrtc.SDPTypeOffer && (makingOffer || conn.SignalingState() != webrtc.SignalingStateStable)
+		ignoreOffer = !polite && collision
+		mux.Unlock()
+		if ignoreOffer {
+			return nil
+		}
+
+		if collision {
+			rollback := webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}
+			if err := conn.SetLocalDescription(rollback); err != nil {
+				return err
+			}
+		}
+		if err := conn.SetRemoteDescription(sdp); err != nil {
+			return err
+		}
+
+		mux.Lock()
+		flush()
+		mux.Unlock()
+
+		if s.Type != webrtc.SDPTypeOffer {
+			return nil
+		}
+
+		answer, err := conn.CreateAnswer(nil)
+		if err != nil {
+			return err
+		}
+		if err := conn.SetLocalDescription(answer); err != nil {
+			return err
+		}
+		return sig.sdp(answer)
+	})
+
+	// call side
+	cli.Bind(CandidateProcedureName, &sig.fnCandidate)
+	cli.Bind(SdpProcedureName, &sig.fnSdp)
+
+	conn.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		desc := conn.RemoteDescription()
+		if desc == nil {
+			pending = append(pending, candidate)
+		} else {
+			go sig.candidate(candidate)
+		}
+	})
+
+	fn := func(restart bool) error {
+		mux.Lock()
+		makingOffer = true
+		mux.Unlock()
+		defer func() {
+			mux.Lock()
+			makingOffer = false
+			mux.Unlock()
+		}()
+
+		offer, err := conn.CreateOffer(&webrtc.OfferOptions{ICERestart: restart})
+		if err != nil {
+			return err
+		}
+		if err := conn.SetLocalDescription(offer); err != nil {
+			return err
+		}
+
+		return sig.sdp(offer)
+	}
+
+	return fn, nil
+}
+
 type writer Channel
 
 func (x *writer) Cancel() error {
@@ -203,6 +421,7 @@ func (x *writer) Cancel() error {
 }
 
 func (x *writer) Close() error {
+	(*Channel)(x).waitBufferedLow()
 	err := x.V.Send(x.buf)
 	x.buf = x.buf[:0]
 	return err
@@ -212,3 +431,30 @@ func (x *writer) Write(b []byte) (int, error) {
 	x.buf = append(x.buf, b...)
 	return len(b), nil
 }
+
+// writerAsync is the [Channel.WriterAsync] counterpart to writer.
+type writerAsync struct {
+	*Channel
+	buf  []byte
+	done chan error
+}
+
+func (x *writerAsync) Cancel() error {
+	x.buf = x.buf[:0]
+	return nil
+}
+
+func (x *writerAsync) Close() error {
+	buf := x.buf
+	x.buf = nil
+	go func() {
+		x.waitBufferedLow()
+		x.done <- x.V.Send(buf)
+	}()
+	return nil
+}
+
+func (x *writerAsync) Write(b []byte) (int, error) {
+	x.buf = append(x.buf, b...)
+	return len(b), nil
+}
@@ -7,6 +7,7 @@ package webrtc
 import (
 	"github.com/blitz-frost/wasm"
 	"github.com/blitz-frost/wasm/media"
+	"github.com/pion/webrtc/v4"
 )
 
 func (x *Channel) ErrorHandle(fn func(error)) {
@@ -17,7 +18,9 @@ func (x *Channel) ErrorHandle(fn func(error)) {
 type Conn struct {
 	V wasm.Object
 
-	trackFn wasm.DynamicFunction
+	trackFn     wasm.DynamicFunction
+	iceStateFn  wasm.DynamicFunction
+	connStateFn wasm.DynamicFunction
 }
 
 func (x *Conn) SenderRemove(sender Sender) {
@@ -59,6 +62,52 @@ func (x *Conn) TrackHandle(fn func(*media.Track, []media.Stream, Transceiver)) {
 
 func (x *Conn) Wipe() {
 	x.trackFn.Wipe()
+	x.iceStateFn.Wipe()
+	x.connStateFn.Wipe()
+}
+
+// RestartICE invokes the JS peer connection's restartIce method, then publishes the resulting offer through renegotiate, as returned by SignalOffer or SignalPeer.
+func (x *Conn) RestartICE(renegotiate Renegotiate) error {
+	x.V.Call("restartIce")
+	return renegotiate(true)
+}
+
+// StateHandle registers fn to be called whenever the ICE connection state or the overall peer connection state changes.
+//
+// A common use is to watch for an ICEConnectionStateDisconnected state, and call RestartICE if it persists past an application defined timeout.
+func (x *Conn) StateHandle(fn func(webrtc.ICEConnectionState, webrtc.PeerConnectionState)) {
+	state := func(this wasm.Value, args []wasm.Value) (wasm.Any, error) {
+		ice := webrtc.NewICEConnectionState(x.V.Get("iceConnectionState").String())
+		conn := peerConnectionStateParse(x.V.Get("connectionState").String())
+		fn(ice, conn)
+		return nil, nil
+	}
+
+	x.iceStateFn.Remake(wasm.InterfaceFunc(state))
+	x.V.Set("oniceconnectionstatechange", x.iceStateFn.Value())
+
+	x.connStateFn.Remake(wasm.InterfaceFunc(state))
+	x.V.Set("onconnectionstatechange", x.connStateFn.Value())
+}
+
+// peerConnectionStateParse mirrors pion/webrtc's own (unexported) string parsing, as it exposes no public equivalent to reuse here.
+func peerConnectionStateParse(raw string) webrtc.PeerConnectionState {
+	switch raw {
+	case "new":
+		return webrtc.PeerConnectionStateNew
+	case "connecting":
+		return webrtc.PeerConnectionStateConnecting
+	case "connected":
+		return webrtc.PeerConnectionStateConnected
+	case "disconnected":
+		return webrtc.PeerConnectionStateDisconnected
+	case "failed":
+		return webrtc.PeerConnectionStateFailed
+	case "closed":
+		return webrtc.PeerConnectionStateClosed
+	default:
+		return webrtc.PeerConnectionStateUnknown
+	}
 }
 
 // All properties are defined as optional in the JS API, so they may return zero values.